@@ -0,0 +1,48 @@
+package framework
+
+import "context"
+
+// EventHandler lets callers observe the internal phases of a reconciliation
+// loop without wrapping every Resource. Implementations are invoked
+// synchronously at the existing step boundaries inside ProcessDelete and
+// ProcessUpdate, so they must not block for long or they will delay the
+// reconciliation they are observing.
+type EventHandler interface {
+	// OnResourceStart is called before a resource's first step of a given
+	// phase ("delete" or "update") is executed.
+	OnResourceStart(ctx context.Context, resource Resource, phase string)
+	// OnGetCurrentState is called after a resource's GetCurrentState step.
+	OnGetCurrentState(ctx context.Context, resource Resource, currentState interface{}, err error)
+	// OnPatchComputed is called after a resource's NewDeletePatch or
+	// NewUpdatePatch step.
+	OnPatchComputed(ctx context.Context, resource Resource, patch *Patch)
+	// OnApply is called after a resource's ApplyCreateChange,
+	// ApplyDeleteChange or ApplyUpdateChange step. kind is one of "create",
+	// "delete" or "update".
+	OnApply(ctx context.Context, resource Resource, kind string, change interface{}, err error)
+	// OnResourceEnd is called once a resource is done with the current phase:
+	// after its last step, or after an earlier step returned err or the
+	// resource or reconciliation was canceled.
+	OnResourceEnd(ctx context.Context, resource Resource, err error)
+	// OnReconcileEnd is called once every resource of a reconciliation loop
+	// has been processed, or reconciliation stopped early due to err.
+	OnReconcileEnd(ctx context.Context, obj interface{}, err error)
+}
+
+// nopEventHandler is the default EventHandler used when Config.EventHandler
+// is not set.
+type nopEventHandler struct{}
+
+func (nopEventHandler) OnResourceStart(ctx context.Context, resource Resource, phase string) {}
+
+func (nopEventHandler) OnGetCurrentState(ctx context.Context, resource Resource, currentState interface{}, err error) {
+}
+
+func (nopEventHandler) OnPatchComputed(ctx context.Context, resource Resource, patch *Patch) {}
+
+func (nopEventHandler) OnApply(ctx context.Context, resource Resource, kind string, change interface{}, err error) {
+}
+
+func (nopEventHandler) OnResourceEnd(ctx context.Context, resource Resource, err error) {}
+
+func (nopEventHandler) OnReconcileEnd(ctx context.Context, obj interface{}, err error) {}
@@ -0,0 +1,27 @@
+package framework
+
+import "context"
+
+// eventHandlerContextKey is used to carry the configured EventHandler through
+// ctx so ProcessDelete and ProcessUpdate can reach it without changing their
+// public signature, mirroring the reconciliationcanceledcontext and
+// resourcecanceledcontext packages.
+type eventHandlerContextKey string
+
+const eventHandlerKey eventHandlerContextKey = "eventHandler"
+
+// newEventHandlerContext returns a copy of ctx carrying h.
+func newEventHandlerContext(ctx context.Context, h EventHandler) context.Context {
+	return context.WithValue(ctx, eventHandlerKey, h)
+}
+
+// eventHandlerFromContext returns the EventHandler carried in ctx, or
+// nopEventHandler{} if ctx does not carry one.
+func eventHandlerFromContext(ctx context.Context) EventHandler {
+	h, ok := ctx.Value(eventHandlerKey).(EventHandler)
+	if !ok {
+		return nopEventHandler{}
+	}
+
+	return h
+}
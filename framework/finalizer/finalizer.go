@@ -0,0 +1,262 @@
+// Package finalizer ensures a configured finalizer string is present on
+// observed runtime objects and removes it again once deletion has been
+// reconciled. It guarantees that Framework.ProcessDelete always has a chance
+// to run against an object's spec before the Kubernetes API server is allowed
+// to remove it for good.
+package finalizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// maxConflictRetries bounds how many times EnsureCreated/EnsureDeleted
+// re-read the object and retry their patch after a resourceVersion conflict,
+// i.e. after another writer changed metadata.finalizers concurrently.
+const maxConflictRetries = 5
+
+// Config represents the configuration used to create a new Finalizer.
+type Config struct {
+	// Logger is the logger used for this finalizer.
+	Logger micrologger.Logger
+	// RESTClient is used to patch the metadata.finalizers field of observed
+	// objects.
+	RESTClient rest.Interface
+	// Resource is the plural name of the resource reconciled by the framework
+	// this finalizer is attached to, e.g. "awsconfigs".
+	Resource string
+
+	// Finalizer is the finalizer string added to and removed from observed
+	// objects, e.g. "operatorkit.giantswarm.io/my-operator".
+	Finalizer string
+}
+
+// Finalizer ensures Config.Finalizer is present on observed objects and
+// removes it again once all resources of a reconciliation loop have been
+// deleted successfully.
+type Finalizer struct {
+	logger     micrologger.Logger
+	restClient rest.Interface
+	resource   string
+
+	finalizer string
+}
+
+// New creates a new configured Finalizer.
+func New(config Config) (*Finalizer, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+	if config.RESTClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.RESTClient must not be empty")
+	}
+	if config.Resource == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Resource must not be empty")
+	}
+	if config.Finalizer == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Finalizer must not be empty")
+	}
+
+	f := &Finalizer{
+		logger:     config.Logger,
+		restClient: config.RESTClient,
+		resource:   config.Resource,
+
+		finalizer: config.Finalizer,
+	}
+
+	return f, nil
+}
+
+// EnsureCreated adds the configured finalizer to obj's metadata.finalizers if
+// it is not already present.
+func (f *Finalizer) EnsureCreated(ctx context.Context, obj interface{}) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	changed, err := f.ensureFinalizers(ctx, accessor, func(finalizers []string) ([]string, bool) {
+		if containsFinalizer(finalizers, f.finalizer) {
+			return finalizers, false
+		}
+		return append(finalizers, f.finalizer), true
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if changed {
+		f.logger.LogCtx(ctx, "debug", "added finalizer", "finalizer", f.finalizer)
+	}
+
+	return nil
+}
+
+// EnsureDeleted removes the configured finalizer from obj's
+// metadata.finalizers if it is present.
+func (f *Finalizer) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	changed, err := f.ensureFinalizers(ctx, accessor, func(finalizers []string) ([]string, bool) {
+		if !containsFinalizer(finalizers, f.finalizer) {
+			return finalizers, false
+		}
+		return removeFinalizer(finalizers, f.finalizer), true
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if changed {
+		f.logger.LogCtx(ctx, "debug", "removed finalizer", "finalizer", f.finalizer)
+	}
+
+	return nil
+}
+
+// ensureFinalizers patches accessor's metadata.finalizers to whatever mutate
+// returns, carrying accessor's resourceVersion in the patch body so the API
+// server rejects the write instead of silently clobbering a concurrent
+// finalizer change (e.g. another controller's, or this framework's own retry
+// for a stale event) made since accessor was read. On a conflict it re-reads
+// the object's current finalizers and resourceVersion, re-applies mutate and
+// retries, up to maxConflictRetries times. mutate's second return value
+// reports whether it actually wants to change anything; ensureFinalizers
+// stops, without patching, once it returns false.
+func (f *Finalizer) ensureFinalizers(ctx context.Context, accessor meta.Object, mutate func(finalizers []string) ([]string, bool)) (bool, error) {
+	namespace := accessor.GetNamespace()
+	name := accessor.GetName()
+	finalizers := accessor.GetFinalizers()
+	resourceVersion := accessor.GetResourceVersion()
+
+	changed := false
+
+	o := func() error {
+		next, ok := mutate(finalizers)
+		if !ok {
+			return nil
+		}
+
+		patchErr := f.patchFinalizers(ctx, namespace, name, resourceVersion, next)
+		if patchErr == nil {
+			changed = true
+			return nil
+		}
+		if !k8serrors.IsConflict(patchErr) {
+			return backoff.Permanent(microerror.Mask(patchErr))
+		}
+
+		current, currentResourceVersion, err := f.getCurrent(ctx, namespace, name)
+		if err != nil {
+			return backoff.Permanent(microerror.Mask(err))
+		}
+		finalizers = current
+		resourceVersion = currentResourceVersion
+
+		return microerror.Mask(patchErr)
+	}
+
+	n := func(err error, dur time.Duration) {
+		f.logger.LogCtx(ctx, "warning", fmt.Sprintf("retrying finalizer patch due to conflict (%s)", err.Error()))
+	}
+
+	err := backoff.RetryNotify(o, backoff.WithMaxTries(backoff.NewConstantBackOff(10*time.Millisecond), maxConflictRetries+1), n)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return changed, nil
+}
+
+// getCurrent fetches the current finalizers and resourceVersion of the
+// object identified by namespace/name. It decodes the response into
+// unstructured.Unstructured instead of a concrete type because Finalizer is
+// only ever given the metav1.Object accessor of the original obj, never its
+// concrete Go type.
+func (f *Finalizer) getCurrent(ctx context.Context, namespace, name string) ([]string, string, error) {
+	req := f.restClient.Get().Resource(f.resource).Name(name)
+	if namespace != "" {
+		req = req.Namespace(namespace)
+	}
+
+	body, err := req.Do(ctx).Raw()
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	var current unstructured.Unstructured
+	err = current.UnmarshalJSON(body)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	return current.GetFinalizers(), current.GetResourceVersion(), nil
+}
+
+func (f *Finalizer) patchFinalizers(ctx context.Context, namespace, name, resourceVersion string, finalizers []string) error {
+	patch := struct {
+		Metadata struct {
+			ResourceVersion string   `json:"resourceVersion"`
+			Finalizers      []string `json:"finalizers"`
+		} `json:"metadata"`
+	}{}
+	patch.Metadata.ResourceVersion = resourceVersion
+	patch.Metadata.Finalizers = finalizers
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	req := f.restClient.Patch(types.MergePatchType).
+		Resource(f.resource).
+		Name(name).
+		Body(body)
+
+	if namespace != "" {
+		req = req.Namespace(namespace)
+	}
+
+	err = req.Do(ctx).Error()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func containsFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	kept := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
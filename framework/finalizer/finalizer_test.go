@@ -0,0 +1,245 @@
+package finalizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+// capturingRESTClient wraps a restfake.RESTClient and records every request
+// body it is asked to send, so tests can assert on the patch a Finalizer
+// sent without standing up a real API server. respond, when set, lets a test
+// control the status code and body returned for each request in turn; the
+// zero value always answers 200 with an empty object, which is enough for
+// tests that never need to exercise a conflict/retry.
+type capturingRESTClient struct {
+	*restfake.RESTClient
+
+	requests []capturedRequest
+	respond  func(req *http.Request) (int, []byte)
+}
+
+type capturedRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+func newCapturingRESTClient(t *testing.T) *capturingRESTClient {
+	c := &capturingRESTClient{}
+
+	c.RESTClient = &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         schema.GroupVersion{Group: "example.com", Version: "v1"},
+		VersionedAPIPath:     "/apis/example.com/v1",
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("reading request body: %s", err)
+			}
+
+			c.requests = append(c.requests, capturedRequest{
+				method: req.Method,
+				path:   req.URL.Path,
+				body:   string(body),
+			})
+
+			statusCode := http.StatusOK
+			respBody := []byte("{}")
+			if c.respond != nil {
+				statusCode, respBody = c.respond(req)
+			}
+
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(bytes.NewReader(respBody)),
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+			}, nil
+		}),
+	}
+
+	return c
+}
+
+func newTestFinalizer(t *testing.T, restClient rest.Interface) *Finalizer {
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("micrologger.New: %s", err)
+	}
+
+	f, err := New(Config{
+		Logger:     logger,
+		RESTClient: restClient,
+		Resource:   "examples",
+		Finalizer:  "operatorkit.giantswarm.io/test-operator",
+	})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	return f
+}
+
+func objectWithFinalizers(finalizers ...string) *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{
+		Name:       "my-object",
+		Finalizers: finalizers,
+	}
+}
+
+func unmarshalFinalizerPatch(t *testing.T, body string) (string, []string) {
+	var patch struct {
+		Metadata struct {
+			ResourceVersion string   `json:"resourceVersion"`
+			Finalizers      []string `json:"finalizers"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(body), &patch); err != nil {
+		t.Fatalf("unmarshaling patch body: %s", err)
+	}
+	return patch.Metadata.ResourceVersion, patch.Metadata.Finalizers
+}
+
+// TestFinalizerAddedOnFirstObservation verifies that EnsureCreated patches
+// the finalizer into metadata.finalizers the first time an object without it
+// is observed.
+func TestFinalizerAddedOnFirstObservation(t *testing.T) {
+	rc := newCapturingRESTClient(t)
+	f := newTestFinalizer(t, rc)
+
+	obj := objectWithFinalizers()
+
+	err := f.EnsureCreated(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("EnsureCreated: %s", err)
+	}
+
+	if len(rc.requests) != 1 {
+		t.Fatalf("expected 1 patch request, got %d", len(rc.requests))
+	}
+
+	_, finalizers := unmarshalFinalizerPatch(t, rc.requests[0].body)
+	if len(finalizers) != 1 || finalizers[0] != "operatorkit.giantswarm.io/test-operator" {
+		t.Fatalf("unexpected finalizers in patch: %#v", finalizers)
+	}
+}
+
+// TestFinalizerNotReaddedWhenAlreadyPresent verifies that EnsureCreated is a
+// no-op, issuing no patch at all, once the finalizer is already present.
+func TestFinalizerNotReaddedWhenAlreadyPresent(t *testing.T) {
+	rc := newCapturingRESTClient(t)
+	f := newTestFinalizer(t, rc)
+
+	obj := objectWithFinalizers("operatorkit.giantswarm.io/test-operator")
+
+	err := f.EnsureCreated(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("EnsureCreated: %s", err)
+	}
+
+	if len(rc.requests) != 0 {
+		t.Fatalf("expected no patch request, got %d", len(rc.requests))
+	}
+}
+
+// TestFinalizerRemovedOnlyAfterSuccess verifies that EnsureDeleted patches
+// the finalizer out of metadata.finalizers. A Framework only calls this once
+// every resource of a reconciliation loop has been deleted successfully.
+func TestFinalizerRemovedOnlyAfterSuccess(t *testing.T) {
+	rc := newCapturingRESTClient(t)
+	f := newTestFinalizer(t, rc)
+
+	obj := objectWithFinalizers("operatorkit.giantswarm.io/test-operator", "other.io/finalizer")
+
+	err := f.EnsureDeleted(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("EnsureDeleted: %s", err)
+	}
+
+	if len(rc.requests) != 1 {
+		t.Fatalf("expected 1 patch request, got %d", len(rc.requests))
+	}
+
+	_, finalizers := unmarshalFinalizerPatch(t, rc.requests[0].body)
+	if len(finalizers) != 1 || finalizers[0] != "other.io/finalizer" {
+		t.Fatalf("unexpected finalizers in patch: %#v", finalizers)
+	}
+}
+
+// TestFinalizerPatchRetriesOnConflict verifies that when the PATCH races
+// against a concurrent finalizer change made by another writer, EnsureDeleted
+// does not clobber it: it re-reads the object's current finalizers and
+// resourceVersion after the conflict and retries the patch against that
+// fresher state instead of the stale one it started with.
+func TestFinalizerPatchRetriesOnConflict(t *testing.T) {
+	rc := newCapturingRESTClient(t)
+	f := newTestFinalizer(t, rc)
+
+	patches := 0
+	rc.respond = func(req *http.Request) (int, []byte) {
+		if req.Method == http.MethodPatch {
+			patches++
+			if patches == 1 {
+				return http.StatusConflict, []byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","message":"conflict","code":409}`)
+			}
+			return http.StatusOK, []byte("{}")
+		}
+
+		return http.StatusOK, []byte(`{"metadata":{"resourceVersion":"2","finalizers":["operatorkit.giantswarm.io/test-operator","other.io/finalizer"]}}`)
+	}
+
+	obj := objectWithFinalizers("operatorkit.giantswarm.io/test-operator")
+	obj.ResourceVersion = "1"
+
+	err := f.EnsureDeleted(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("EnsureDeleted: %s", err)
+	}
+
+	var patchBodies []string
+	for _, req := range rc.requests {
+		if req.method == http.MethodPatch {
+			patchBodies = append(patchBodies, req.body)
+		}
+	}
+	if len(patchBodies) != 2 {
+		t.Fatalf("expected 2 patch requests (one conflicting, one retried), got %d", len(patchBodies))
+	}
+
+	resourceVersion, finalizers := unmarshalFinalizerPatch(t, patchBodies[1])
+	if resourceVersion != "2" {
+		t.Fatalf("expected retried patch to carry the freshly-read resourceVersion %q, got %q", "2", resourceVersion)
+	}
+	if len(finalizers) != 1 || finalizers[0] != "other.io/finalizer" {
+		t.Fatalf("expected retried patch to only drop our finalizer from the freshly-read set, got %#v", finalizers)
+	}
+}
+
+// TestFinalizerNotRemovedWhenAbsent verifies that EnsureDeleted is a no-op
+// when the finalizer isn't present, e.g. because a previous run already
+// removed it before crashing partway through deletion.
+func TestFinalizerNotRemovedWhenAbsent(t *testing.T) {
+	rc := newCapturingRESTClient(t)
+	f := newTestFinalizer(t, rc)
+
+	obj := objectWithFinalizers("other.io/finalizer")
+
+	err := f.EnsureDeleted(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("EnsureDeleted: %s", err)
+	}
+
+	if len(rc.requests) != 0 {
+		t.Fatalf("expected no patch request, got %d", len(rc.requests))
+	}
+}
@@ -13,11 +13,16 @@ import (
 	"github.com/giantswarm/micrologger/loggermeta"
 	"github.com/prometheus/client_golang/prometheus"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/giantswarm/operatorkit/client/k8scrdclient"
 	"github.com/giantswarm/operatorkit/framework/context/reconciliationcanceledcontext"
 	"github.com/giantswarm/operatorkit/framework/context/resourcecanceledcontext"
+	"github.com/giantswarm/operatorkit/framework/finalizer"
 	"github.com/giantswarm/operatorkit/informer"
 )
 
@@ -41,6 +46,33 @@ type Config struct {
 	ResourceRouter *ResourceRouter
 
 	BackOffFactory func() backoff.BackOff
+	// Concurrency is the number of workers processing the event queue in
+	// parallel. Reconciliation is only ever serialized per object key
+	// (namespace/name), never across the whole queue, so distinct objects are
+	// reconciled concurrently while repeated events for the same object are
+	// not. Defaults to 1, which reproduces the historic fully serialized
+	// behaviour.
+	Concurrency int
+
+	// Finalizer is the finalizer string this framework manages on every
+	// observed object, e.g. "operatorkit.giantswarm.io/my-operator". When set,
+	// the framework ensures the finalizer is present before an object is ever
+	// handed to ProcessUpdate, routes objects with a non-nil
+	// DeletionTimestamp through ProcessDelete regardless of whether the
+	// informer ever delivers a DELETE event, and only removes the finalizer
+	// once ProcessDelete succeeds for every resource. RESTClient must be set
+	// when Finalizer is used, and CRD must be set so the finalizer knows which
+	// REST resource to patch. Leaving Finalizer empty preserves the historic
+	// behaviour of reconciling deletion purely from informer DELETE events.
+	Finalizer string
+	// RESTClient is used to patch metadata.finalizers on observed objects. Only
+	// required when Finalizer is set.
+	RESTClient rest.Interface
+
+	// EventHandler is invoked at the phase boundaries of ProcessDelete and
+	// ProcessUpdate, e.g. to record structured metrics or emit Kubernetes
+	// events. Defaults to a no-op implementation.
+	EventHandler EventHandler
 }
 
 type Framework struct {
@@ -50,10 +82,18 @@ type Framework struct {
 	logger         micrologger.Logger
 	resourceRouter *ResourceRouter
 
-	bootOnce sync.Once
-	mutex    sync.Mutex
+	// pending holds the latest observed event per object key. Events for the
+	// same key overwrite each other so a worker always reconciles against the
+	// newest known state instead of working through a backlog of stale ones.
+	pending      map[string]watch.Event
+	pendingMutex sync.Mutex
+	queue        workqueue.RateLimitingInterface
 
 	backOffFactory func() backoff.BackOff
+	concurrency    int
+
+	finalizer    *finalizer.Finalizer
+	eventHandler EventHandler
 }
 
 // New creates a new configured operator framework.
@@ -70,10 +110,42 @@ func New(config Config) (*Framework, error) {
 	if config.ResourceRouter == nil {
 		return nil, microerror.Maskf(invalidConfigError, "config.ResourceRouter must not be empty")
 	}
+	if config.Finalizer != "" && config.RESTClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.RESTClient must not be empty when config.Finalizer is set")
+	}
+	if config.Finalizer != "" && config.CRD == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.CRD must not be empty when config.Finalizer is set")
+	}
+	if config.Concurrency < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Concurrency must not be negative")
+	}
 
 	if config.BackOffFactory == nil {
 		config.BackOffFactory = DefaultBackOffFactory()
 	}
+	if config.Concurrency == 0 {
+		config.Concurrency = 1
+	}
+	if config.EventHandler == nil {
+		config.EventHandler = nopEventHandler{}
+	}
+
+	var finalizerHelper *finalizer.Finalizer
+	if config.Finalizer != "" {
+		c := finalizer.Config{
+			Logger:     config.Logger,
+			RESTClient: config.RESTClient,
+			Resource:   config.CRD.Spec.Names.Plural,
+
+			Finalizer: config.Finalizer,
+		}
+
+		var err error
+		finalizerHelper, err = finalizer.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
 
 	f := &Framework{
 		crd:            config.CRD,
@@ -82,106 +154,123 @@ func New(config Config) (*Framework, error) {
 		logger:         config.Logger,
 		resourceRouter: config.ResourceRouter,
 
-		bootOnce: sync.Once{},
-		mutex:    sync.Mutex{},
+		pending:      map[string]watch.Event{},
+		pendingMutex: sync.Mutex{},
 
 		backOffFactory: config.BackOffFactory,
+		concurrency:    config.Concurrency,
+
+		finalizer:    finalizerHelper,
+		eventHandler: config.EventHandler,
 	}
 
 	return f, nil
 }
 
-func (f *Framework) Boot() {
-	ctx := context.TODO()
-
-	f.bootOnce.Do(func() {
-		operation := func() error {
-			err := f.bootWithError(ctx)
-			if err != nil {
-				return microerror.Mask(err)
-			}
-
-			return nil
-		}
-
-		notifier := func(err error, d time.Duration) {
-			f.logger.LogCtx(ctx, "warning", fmt.Sprintf("retrying operator boot due to error: %#v", microerror.Mask(err)))
-		}
-
-		err := backoff.RetryNotify(operation, f.backOffFactory(), notifier)
+// Boot ensures the framework's CRD exists, if configured, and then starts
+// list/watching and reconciling observed objects until ctx is cancelled or
+// backoff gives up retrying a boot failure. Unlike the previous version of
+// Boot, it never terminates the process itself: callers running several
+// Frameworks are expected to build ctx with context.WithCancelCause and
+// cancel it with the returned error, so every other Framework sharing ctx
+// observes the failure through ctx.Done() and shuts down on its own. See
+// RunUntilDone for a helper reproducing the historic exit-on-error
+// behaviour.
+func (f *Framework) Boot(ctx context.Context) error {
+	operation := func() error {
+		err := f.bootWithError(ctx)
 		if err != nil {
-			f.logger.LogCtx(ctx, "error", fmt.Sprintf("stop operator boot retries due to too many errors: %#v", microerror.Mask(err)))
-			os.Exit(1)
+			return microerror.Mask(err)
 		}
-	})
-}
 
-// DeleteFunc executes the framework's ProcessDelete function.
-func (f *Framework) DeleteFunc(obj interface{}) {
-	// DeleteFunc/UpdateFunc is synchronized to make sure only one of them is
-	// executed at a time. DeleteFunc/UpdateFunc is not thread safe. This is
-	// important because the source of truth for an operator are the reconciled
-	// resources. In case we would run the operator logic in parallel, we would
-	// run into race conditions.
-	f.mutex.Lock()
-	defer f.mutex.Unlock()
+		return nil
+	}
 
-	resourceSet, err := f.resourceRouter.ResourceSet(obj)
-	if err != nil {
-		f.logger.Log("error", fmt.Sprintf("%#v", err), "event", "delete")
-		return
+	notifier := func(err error, d time.Duration) {
+		f.logger.LogCtx(ctx, "warning", fmt.Sprintf("retrying operator boot due to error: %#v", microerror.Mask(err)))
 	}
 
-	ctx, err := resourceSet.InitCtx(context.Background(), obj)
+	err := backoff.RetryNotify(operation, f.backOffFactory(), notifier)
 	if err != nil {
-		f.logger.Log("error", fmt.Sprintf("%#v", err), "event", "delete")
-		return
+		f.logger.LogCtx(ctx, "error", fmt.Sprintf("stop operator boot retries due to too many errors: %#v", microerror.Mask(err)))
+		return microerror.Mask(err)
 	}
 
-	f.logger.LogCtx(ctx, "action", "start", "component", "operatorkit", "function", "ProcessDelete")
+	return nil
+}
 
-	err = ProcessDelete(ctx, obj, resourceSet.Resources())
+// RunUntilDone calls f.Boot(ctx) and terminates the process via os.Exit(1)
+// if it returns an error, reproducing the behaviour Boot used to have before
+// it started returning an error instead. It exists to ease migrating
+// existing callers onto the new Boot signature; new code should call
+// f.Boot(ctx) directly and decide for itself how to react to a boot failure,
+// e.g. by cancelling a shared context.
+func RunUntilDone(ctx context.Context, f *Framework) {
+	err := f.Boot(ctx)
 	if err != nil {
-		f.logger.LogCtx(ctx, "error", fmt.Sprintf("%#v", err), "event", "delete")
-		return
+		f.logger.LogCtx(ctx, "error", fmt.Sprintf("%#v", microerror.Mask(err)))
+		os.Exit(1)
 	}
+}
 
-	f.logger.LogCtx(ctx, "action", "end", "component", "operatorkit", "function", "ProcessDelete")
+// DeleteFunc is a drop-in for an informer's DeleteFunc. Instead of running the
+// reconciliation inline it only records the latest delete event for the
+// object's key and schedules the key on the work queue. The actual
+// reconciliation happens on one of the framework's workers, see ProcessEvents.
+func (f *Framework) DeleteFunc(obj interface{}) {
+	f.enqueue(watch.Event{Type: watch.Deleted}, obj)
 }
 
-// UpdateFunc executes the framework's ProcessUpdate function.
+// UpdateFunc is a drop-in for an informer's UpdateFunc. Instead of running the
+// reconciliation inline it only records the latest update event for the
+// object's key and schedules the key on the work queue. The actual
+// reconciliation happens on one of the framework's workers, see ProcessEvents.
 func (f *Framework) UpdateFunc(oldObj, newObj interface{}) {
-	obj := newObj
-
-	// DeleteFunc/UpdateFunc is synchronized to make sure only one of them is
-	// executed at a time. DeleteFunc/UpdateFunc is not thread safe. This is
-	// important because the source of truth for an operator are the reconciled
-	// resources. In case we would run the operator logic in parallel, we would
-	// run into race conditions.
-	f.mutex.Lock()
-	defer f.mutex.Unlock()
+	f.enqueue(watch.Event{Type: watch.Modified}, newObj)
+}
 
-	resourceSet, err := f.resourceRouter.ResourceSet(obj)
-	if err != nil {
-		f.logger.Log("error", fmt.Sprintf("%#v", err), "event", "update")
+// enqueue derives the namespace/name key of obj, stores it as the latest
+// pending event for that key and adds the key to the work queue. Keying
+// reconciliation this way, instead of queueing the object itself, is what
+// lets two different objects reconcile concurrently while repeated events for
+// the same object never run at the same time: the work queue guarantees a key
+// is never handed to two workers at once.
+func (f *Framework) enqueue(event watch.Event, obj interface{}) {
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		f.logger.Log("error", fmt.Sprintf("%#v", microerror.Maskf(executionFailedError, "object does not implement runtime.Object")), "event", "enqueue")
 		return
 	}
+	event.Object = runtimeObj
 
-	ctx, err := resourceSet.InitCtx(context.Background(), obj)
+	key, err := objectKey(obj)
 	if err != nil {
-		f.logger.Log("error", fmt.Sprintf("%#v", err), "event", "update")
+		f.logger.Log("error", fmt.Sprintf("%#v", err), "event", "enqueue")
 		return
 	}
 
-	f.logger.LogCtx(ctx, "action", "start", "component", "operatorkit", "function", "ProcessUpdate")
+	f.pendingMutex.Lock()
+	f.pending[key] = event
+	f.pendingMutex.Unlock()
 
-	err = ProcessUpdate(ctx, obj, resourceSet.Resources())
+	queueAdds.Inc()
+	f.queue.Add(key)
+	queueDepth.Set(float64(f.queue.Len()))
+}
+
+// objectKey returns the namespace/name key used to serialize reconciliation
+// of obj on the work queue.
+func objectKey(obj interface{}) (string, error) {
+	accessor, err := meta.Accessor(obj)
 	if err != nil {
-		f.logger.LogCtx(ctx, "error", fmt.Sprintf("%#v", err), "event", "update")
-		return
+		return "", microerror.Mask(err)
 	}
 
-	f.logger.LogCtx(ctx, "action", "end", "component", "operatorkit", "function", "ProcessUpdate")
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName(), nil
+	}
+
+	return accessor.GetNamespace() + "/" + accessor.GetName(), nil
 }
 
 // ProcessDelete is a drop-in for an informer's DeleteFunc. It receives the
@@ -205,171 +294,241 @@ func ProcessDelete(ctx context.Context, obj interface{}, resources []Resource) e
 		return microerror.Maskf(executionFailedError, "resources must not be empty")
 	}
 
-	for _, r := range resources {
-		var err error
+	handler := eventHandlerFromContext(ctx)
 
-		var currentState interface{}
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+	for _, r := range resources {
+		handler.OnResourceStart(ctx, r, "delete")
+
+		// canceled reports that reconciliationcanceledcontext fired and
+		// ProcessDelete must return immediately, as opposed to err, which
+		// reports a real resource failure. Both are funnelled through this
+		// closure so a single deferred call can report the resource's actual
+		// outcome to handler.OnResourceEnd, including on every early return
+		// below, instead of only on the happy path.
+		var canceled bool
+		err := func() (err error) {
+			// ctx is bound here, so OnResourceEnd receives the same ctx as
+			// OnResourceStart even when a step below replaces ctx.
+			defer func(ctx context.Context) {
+				handler.OnResourceEnd(ctx, r, err)
+			}(ctx)
+
+			var currentState interface{}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "GetCurrentState"
-				defer delete(meta.KeyVals, "function")
-			}
-			currentState, err = r.GetCurrentState(ctx, obj)
-			if err != nil {
-				return microerror.Mask(err)
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "GetCurrentState"
+					defer delete(meta.KeyVals, "function")
+				}
+				currentState, err = r.GetCurrentState(ctx, obj)
+				handler.OnGetCurrentState(ctx, r, currentState, err)
+				if err != nil {
+					return microerror.Mask(err)
+				}
 			}
-		}
 
-		var desiredState interface{}
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			var desiredState interface{}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "GetDesiredState"
-				defer delete(meta.KeyVals, "function")
-			}
-			desiredState, err = r.GetDesiredState(ctx, obj)
-			if err != nil {
-				return microerror.Mask(err)
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "GetDesiredState"
+					defer delete(meta.KeyVals, "function")
+				}
+				desiredState, err = r.GetDesiredState(ctx, obj)
+				if err != nil {
+					return microerror.Mask(err)
+				}
 			}
-		}
 
-		var patch *Patch
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			var patch *Patch
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "NewDeletePatch"
-				defer delete(meta.KeyVals, "function")
-			}
-			patch, err = r.NewDeletePatch(ctx, obj, currentState, desiredState)
-			if err != nil {
-				return microerror.Mask(err)
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "NewDeletePatch"
+					defer delete(meta.KeyVals, "function")
+				}
+				patch, err = r.NewDeletePatch(ctx, obj, currentState, desiredState)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+				handler.OnPatchComputed(ctx, r, patch)
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				createChange, ok := patch.getCreateChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					createChange, ok := patch.getCreateChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyCreateChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyCreateChange(ctx, obj, createChange)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyCreateChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyCreateChange(ctx, obj, createChange)
+						handler.OnApply(ctx, r, "create", createChange, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				deleteChange, ok := patch.getDeleteChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					deleteChange, ok := patch.getDeleteChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyDeleteChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyDeleteChange(ctx, obj, deleteChange)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyDeleteChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyDeleteChange(ctx, obj, deleteChange)
+						handler.OnApply(ctx, r, "delete", deleteChange, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				updateChange, ok := patch.getUpdateChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					updateChange, ok := patch.getUpdateChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyUpdateChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyUpdateChange(ctx, obj, updateChange)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyUpdateChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyUpdateChange(ctx, obj, updateChange)
+						handler.OnApply(ctx, r, "update", updateChange, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
+
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+		if canceled {
+			return nil
 		}
 	}
 
 	return nil
 }
 
-// ProcessEvents takes the event channels created by the operatorkit informer
-// and executes the framework's event functions accordingly.
-func (f *Framework) ProcessEvents(ctx context.Context, deleteChan chan watch.Event, updateChan chan watch.Event, errChan chan error) {
+// ProcessEvents takes the event channels created by the operatorkit informer,
+// feeds them into a namespace/name-keyed work queue and runs
+// Config.Concurrency workers draining it. Reconciliation of any two different
+// objects can happen in parallel, but repeated events for the same object are
+// always processed one at a time and in order, because the work queue never
+// hands the same key to more than one worker.
+//
+// Failed reconciliations are requeued with exponential backoff via
+// workqueue.AddRateLimited, which takes over the job the retryresource
+// resource used to do at the dispatch layer. Resources can still use
+// retryresource for retrying individual steps of a single reconciliation.
+//
+// ProcessEvents returns the error that made it stop instead of terminating
+// the process, so callers running several Frameworks can decide how to react,
+// e.g. by cancelling a context shared with other Frameworks. It returns nil
+// if ctx is cancelled.
+func (f *Framework) ProcessEvents(ctx context.Context, deleteChan chan watch.Event, updateChan chan watch.Event, errChan chan error) error {
 	operation := func() error {
-		for {
-			select {
-			case e := <-deleteChan:
-				t := prometheus.NewTimer(frameworkHistogram.WithLabelValues("delete"))
-				f.DeleteFunc(e.Object)
-				t.ObserveDuration()
-			case e := <-updateChan:
-				t := prometheus.NewTimer(frameworkHistogram.WithLabelValues("update"))
-				f.UpdateFunc(nil, e.Object)
-				t.ObserveDuration()
-			case err := <-errChan:
-				return microerror.Mask(err)
-			case <-ctx.Done():
-				return nil
-			}
+		// The queue is rebuilt on every attempt because ShutDown(), called
+		// below once the attempt ends, leaves the previous queue unusable.
+		f.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+		var wg sync.WaitGroup
+		workerCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		for i := 0; i < f.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f.worker(workerCtx)
+			}()
 		}
+
+		err := func() error {
+			for {
+				select {
+				case e := <-deleteChan:
+					f.DeleteFunc(e.Object)
+				case e := <-updateChan:
+					f.UpdateFunc(nil, e.Object)
+				case err := <-errChan:
+					return microerror.Mask(err)
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}()
+
+		cancel()
+		f.queue.ShutDown()
+		wg.Wait()
+
+		return err
 	}
 
 	notifier := func(err error, d time.Duration) {
@@ -379,8 +538,168 @@ func (f *Framework) ProcessEvents(ctx context.Context, deleteChan chan watch.Eve
 	err := backoff.RetryNotify(operation, f.backOffFactory(), notifier)
 	if err != nil {
 		f.logger.LogCtx(ctx, "error", fmt.Sprintf("stop operator event processing retries due to too many errors: %#v", microerror.Mask(err)))
-		os.Exit(1)
+		return microerror.Mask(err)
 	}
+
+	return nil
+}
+
+// worker pulls keys off the queue until it is shut down, dispatching each one
+// to processNextWorkItem.
+func (f *Framework) worker(ctx context.Context) {
+	for f.processNextWorkItem(ctx) {
+	}
+}
+
+// processNextWorkItem pops a single key from the queue and reconciles it
+// against the latest pending event recorded for that key. It returns false
+// once the queue has been shut down and drained.
+func (f *Framework) processNextWorkItem(ctx context.Context) bool {
+	keyIf, shutdown := f.queue.Get()
+	if shutdown {
+		return false
+	}
+	key := keyIf.(string)
+	defer f.queue.Done(key)
+	queueDepth.Set(float64(f.queue.Len()))
+
+	f.pendingMutex.Lock()
+	event, ok := f.pending[key]
+	delete(f.pending, key)
+	f.pendingMutex.Unlock()
+
+	if !ok {
+		// The key was already reconciled by a previous call for the same key
+		// and nothing new has been observed since.
+		f.queue.Forget(key)
+		return true
+	}
+
+	var eventType string
+	var err error
+	{
+		t := prometheus.NewTimer(frameworkHistogram.WithLabelValues(eventLabel(event.Type)))
+
+		switch event.Type {
+		case watch.Deleted:
+			eventType = "delete"
+			err = f.reconcileDelete(ctx, event.Object)
+		default:
+			eventType = "update"
+			err = f.reconcileUpdate(ctx, event.Object)
+		}
+
+		t.ObserveDuration()
+	}
+
+	if err != nil {
+		f.logger.LogCtx(ctx, "error", fmt.Sprintf("%#v", err), "event", eventType, "key", key)
+		queueRetries.Inc()
+
+		// Requeueing the key alone is not enough: processNextWorkItem looks
+		// the event back up in f.pending on every pop, and enqueue is the only
+		// other place that fills it in. Without restoring it here, the retry
+		// driven by AddRateLimited would find nothing pending for key and
+		// silently Forget it instead of reconciling again. A newer event
+		// enqueued for key meanwhile is kept rather than overwritten.
+		f.pendingMutex.Lock()
+		if _, ok := f.pending[key]; !ok {
+			f.pending[key] = event
+		}
+		f.pendingMutex.Unlock()
+
+		f.queue.AddRateLimited(key)
+		return true
+	}
+
+	f.queue.Forget(key)
+
+	return true
+}
+
+func eventLabel(t watch.EventType) string {
+	if t == watch.Deleted {
+		return "delete"
+	}
+
+	return "update"
+}
+
+func (f *Framework) reconcileDelete(ctx context.Context, obj interface{}) (err error) {
+	resourceSet, err := f.resourceRouter.ResourceSet(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	ctx, err = resourceSet.InitCtx(ctx, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	ctx = newEventHandlerContext(ctx, f.eventHandler)
+	defer func() { f.eventHandler.OnReconcileEnd(ctx, obj, err) }()
+
+	f.logger.LogCtx(ctx, "action", "start", "component", "operatorkit", "function", "ProcessDelete")
+
+	err = ProcessDelete(ctx, obj, resourceSet.Resources())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	f.logger.LogCtx(ctx, "action", "end", "component", "operatorkit", "function", "ProcessDelete")
+
+	if f.finalizer != nil {
+		err = f.finalizer.EnsureDeleted(ctx, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (f *Framework) reconcileUpdate(ctx context.Context, obj interface{}) (err error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// With a finalizer configured the object is never actually removed from
+	// the API server until we remove the finalizer ourselves, so deletion is
+	// driven off DeletionTimestamp rather than waiting for an informer DELETE
+	// event that may never come, or may have been missed.
+	if f.finalizer != nil && accessor.GetDeletionTimestamp() != nil {
+		return f.reconcileDelete(ctx, obj)
+	}
+
+	if f.finalizer != nil {
+		err = f.finalizer.EnsureCreated(ctx, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	resourceSet, err := f.resourceRouter.ResourceSet(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	ctx, err = resourceSet.InitCtx(ctx, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	ctx = newEventHandlerContext(ctx, f.eventHandler)
+	defer func() { f.eventHandler.OnReconcileEnd(ctx, obj, err) }()
+
+	f.logger.LogCtx(ctx, "action", "start", "component", "operatorkit", "function", "ProcessUpdate")
+
+	err = ProcessUpdate(ctx, obj, resourceSet.Resources())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	f.logger.LogCtx(ctx, "action", "end", "component", "operatorkit", "function", "ProcessUpdate")
+
+	return nil
 }
 
 // ProcessUpdate is a drop-in for an informer's UpdateFunc. It receives the new
@@ -405,145 +724,175 @@ func ProcessUpdate(ctx context.Context, obj interface{}, resources []Resource) e
 		return microerror.Maskf(executionFailedError, "resources must not be empty")
 	}
 
+	handler := eventHandlerFromContext(ctx)
+
 	for _, r := range resources {
-		var err error
+		handler.OnResourceStart(ctx, r, "update")
+
+		var canceled bool
+		err := func() (err error) {
+			// ctx is bound here, so OnResourceEnd receives the same ctx as
+			// OnResourceStart even when a step below replaces ctx.
+			defer func(ctx context.Context) {
+				handler.OnResourceEnd(ctx, r, err)
+			}(ctx)
+
+			var currentState interface{}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-		var currentState interface{}
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "GetCurrentState"
+					defer delete(meta.KeyVals, "function")
+				}
+				currentState, err = r.GetCurrentState(ctx, obj)
+				handler.OnGetCurrentState(ctx, r, currentState, err)
+				if err != nil {
+					return microerror.Mask(err)
+				}
 			}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "GetCurrentState"
-				defer delete(meta.KeyVals, "function")
-			}
-			currentState, err = r.GetCurrentState(ctx, obj)
-			if err != nil {
-				return microerror.Mask(err)
-			}
-		}
-
-		var desiredState interface{}
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			var desiredState interface{}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "GetDesiredState"
-				defer delete(meta.KeyVals, "function")
-			}
-			desiredState, err = r.GetDesiredState(ctx, obj)
-			if err != nil {
-				return microerror.Mask(err)
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "GetDesiredState"
+					defer delete(meta.KeyVals, "function")
+				}
+				desiredState, err = r.GetDesiredState(ctx, obj)
+				if err != nil {
+					return microerror.Mask(err)
+				}
 			}
-		}
 
-		var patch *Patch
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			var patch *Patch
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			meta, ok := loggermeta.FromContext(ctx)
-			if ok {
-				meta.KeyVals["function"] = "NewUpdatePatch"
-				defer delete(meta.KeyVals, "function")
-			}
-			patch, err = r.NewUpdatePatch(ctx, obj, currentState, desiredState)
-			if err != nil {
-				return microerror.Mask(err)
+				meta, ok := loggermeta.FromContext(ctx)
+				if ok {
+					meta.KeyVals["function"] = "NewUpdatePatch"
+					defer delete(meta.KeyVals, "function")
+				}
+				patch, err = r.NewUpdatePatch(ctx, obj, currentState, desiredState)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+				handler.OnPatchComputed(ctx, r, patch)
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				createState, ok := patch.getCreateChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					createState, ok := patch.getCreateChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyCreateChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyCreateChange(ctx, obj, createState)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyCreateChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyCreateChange(ctx, obj, createState)
+						handler.OnApply(ctx, r, "create", createState, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				deleteState, ok := patch.getDeleteChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					deleteState, ok := patch.getDeleteChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyDeleteChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyDeleteChange(ctx, obj, deleteState)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyDeleteChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyDeleteChange(ctx, obj, deleteState)
+						handler.OnApply(ctx, r, "delete", deleteState, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
-		}
 
-		{
-			if reconciliationcanceledcontext.IsCanceled(ctx) {
-				return nil
-			}
-			if resourcecanceledcontext.IsCanceled(ctx) {
-				ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
-				continue
-			}
+			{
+				if reconciliationcanceledcontext.IsCanceled(ctx) {
+					canceled = true
+					return nil
+				}
+				if resourcecanceledcontext.IsCanceled(ctx) {
+					ctx = resourcecanceledcontext.NewContext(ctx, make(chan struct{}))
+					return nil
+				}
 
-			if patch != nil {
-				updateState, ok := patch.getUpdateChange()
-				if ok {
-					meta, ok := loggermeta.FromContext(ctx)
+				if patch != nil {
+					updateState, ok := patch.getUpdateChange()
 					if ok {
-						meta.KeyVals["function"] = "ApplyUpdateChange"
-						defer delete(meta.KeyVals, "function")
-					}
-					err := r.ApplyUpdateChange(ctx, obj, updateState)
-					if err != nil {
-						return microerror.Mask(err)
+						meta, ok := loggermeta.FromContext(ctx)
+						if ok {
+							meta.KeyVals["function"] = "ApplyUpdateChange"
+							defer delete(meta.KeyVals, "function")
+						}
+						err = r.ApplyUpdateChange(ctx, obj, updateState)
+						handler.OnApply(ctx, r, "update", updateState, err)
+						if err != nil {
+							return microerror.Mask(err)
+						}
 					}
 				}
 			}
+
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+		if canceled {
+			return nil
 		}
 	}
 
@@ -567,7 +916,10 @@ func (f *Framework) bootWithError(ctx context.Context) error {
 	f.logger.LogCtx(ctx, "debug", "starting list/watch")
 
 	deleteChan, updateChan, errChan := f.informer.Watch(ctx)
-	f.ProcessEvents(ctx, deleteChan, updateChan, errChan)
+	err := f.ProcessEvents(ctx, deleteChan, updateChan, errChan)
+	if err != nil {
+		return microerror.Mask(err)
+	}
 
 	return nil
 }
@@ -0,0 +1,152 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// newTestFramework builds a Framework with just enough state to exercise the
+// queueing and key-bookkeeping logic in this file. resourceRouter, crdClient
+// and informer are deliberately left unset: ResourceRouter, Resource and
+// informer.Interface are referenced throughout this package but aren't
+// defined anywhere in this tree, so reconcileDelete/reconcileUpdate/Boot
+// cannot be exercised here. That also rules out the concurrent-reconcile and
+// crash-restart integration tests the original requests asked for; this file
+// covers everything below that boundary instead.
+func newTestFramework(t *testing.T) *Framework {
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("micrologger.New: %s", err)
+	}
+
+	return &Framework{
+		logger:  logger,
+		pending: make(map[string]watch.Event),
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+}
+
+func configMap(namespace, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
+
+func TestObjectKeyNamespaced(t *testing.T) {
+	key, err := objectKey(configMap("default", "my-object"))
+	if err != nil {
+		t.Fatalf("objectKey: %s", err)
+	}
+	if key != "default/my-object" {
+		t.Fatalf("expected key %q, got %q", "default/my-object", key)
+	}
+}
+
+func TestObjectKeyClusterScoped(t *testing.T) {
+	key, err := objectKey(configMap("", "my-object"))
+	if err != nil {
+		t.Fatalf("objectKey: %s", err)
+	}
+	if key != "my-object" {
+		t.Fatalf("expected key %q, got %q", "my-object", key)
+	}
+}
+
+// TestEnqueueOverwritesPendingEventForSameKey verifies that repeated events
+// for the same key never pile up: only the latest one is kept, which is what
+// lets a worker always reconcile against the newest observed state.
+func TestEnqueueOverwritesPendingEventForSameKey(t *testing.T) {
+	f := newTestFramework(t)
+
+	obj := configMap("default", "my-object")
+
+	f.enqueue(watch.Event{Type: watch.Added}, obj)
+	f.enqueue(watch.Event{Type: watch.Modified}, obj)
+
+	f.pendingMutex.Lock()
+	event, ok := f.pending["default/my-object"]
+	f.pendingMutex.Unlock()
+
+	if !ok {
+		t.Fatal("expected a pending event for default/my-object")
+	}
+	if event.Type != watch.Modified {
+		t.Fatalf("expected latest event type %q, got %q", watch.Modified, event.Type)
+	}
+}
+
+// TestEnqueueKeepsDistinctKeysIndependent verifies that two different
+// objects get two independent pending entries, which is the bookkeeping that
+// allows processNextWorkItem to reconcile them concurrently while the queue
+// still serializes repeated events per key.
+func TestEnqueueKeepsDistinctKeysIndependent(t *testing.T) {
+	f := newTestFramework(t)
+
+	f.enqueue(watch.Event{Type: watch.Added}, configMap("default", "object-a"))
+	f.enqueue(watch.Event{Type: watch.Added}, configMap("default", "object-b"))
+
+	f.pendingMutex.Lock()
+	_, okA := f.pending["default/object-a"]
+	_, okB := f.pending["default/object-b"]
+	n := len(f.pending)
+	f.pendingMutex.Unlock()
+
+	if !okA || !okB {
+		t.Fatalf("expected pending entries for both keys, got %#v", f.pending)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 pending entries, got %d", n)
+	}
+}
+
+func TestEventLabel(t *testing.T) {
+	if got := eventLabel(watch.Deleted); got != "delete" {
+		t.Fatalf("expected %q for watch.Deleted, got %q", "delete", got)
+	}
+	if got := eventLabel(watch.Modified); got != "update" {
+		t.Fatalf("expected %q for watch.Modified, got %q", "update", got)
+	}
+	if got := eventLabel(watch.Added); got != "update" {
+		t.Fatalf("expected %q for watch.Added, got %q", "update", got)
+	}
+}
+
+// TestQueueDedupsRepeatedAddsOfSameKey verifies the workqueue contract
+// processNextWorkItem relies on to serialize reconciliation per key: adding a
+// key that is already queued, or already being processed, never grows the
+// queue past one outstanding entry for that key.
+func TestQueueDedupsRepeatedAddsOfSameKey(t *testing.T) {
+	f := newTestFramework(t)
+	defer f.queue.ShutDown()
+
+	f.queue.Add("default/object-a")
+	f.queue.Add("default/object-a")
+	if n := f.queue.Len(); n != 1 {
+		t.Fatalf("expected queue length 1 after repeated Add of the same key, got %d", n)
+	}
+
+	key, shutdown := f.queue.Get()
+	if shutdown {
+		t.Fatal("unexpected shutdown")
+	}
+	defer f.queue.Done(key)
+	if key != "default/object-a" {
+		t.Fatalf("expected key %q, got %v", "default/object-a", key)
+	}
+
+	// Re-adding the same key while it is still being processed (before Done)
+	// must not hand it to another worker concurrently; the workqueue instead
+	// marks it dirty and redelivers it only once Done is called.
+	f.queue.Add("default/object-a")
+	if n := f.queue.Len(); n != 0 {
+		t.Fatalf("expected queue length 0 while the key is still being processed, got %d", n)
+	}
+}
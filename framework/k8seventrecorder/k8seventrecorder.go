@@ -0,0 +1,80 @@
+// Package k8seventrecorder implements framework.EventHandler on top of
+// client-go's record.EventRecorder, so a reconciliation failure or success
+// shows up in `kubectl describe` of the reconciled object.
+package k8seventrecorder
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/giantswarm/operatorkit/framework"
+)
+
+// Config represents the configuration used to create a new EventHandler.
+type Config struct {
+	Logger   micrologger.Logger
+	Recorder record.EventRecorder
+}
+
+// EventHandler implements framework.EventHandler by recording a Kubernetes
+// event on the reconciled object once reconciliation ends.
+type EventHandler struct {
+	logger   micrologger.Logger
+	recorder record.EventRecorder
+}
+
+// New creates a new configured EventHandler.
+func New(config Config) (*EventHandler, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+	if config.Recorder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Recorder must not be empty")
+	}
+
+	e := &EventHandler{
+		logger:   config.Logger,
+		recorder: config.Recorder,
+	}
+
+	return e, nil
+}
+
+func (e *EventHandler) OnResourceStart(ctx context.Context, resource framework.Resource, phase string) {
+}
+
+func (e *EventHandler) OnGetCurrentState(ctx context.Context, resource framework.Resource, currentState interface{}, err error) {
+}
+
+func (e *EventHandler) OnPatchComputed(ctx context.Context, resource framework.Resource, patch *framework.Patch) {
+}
+
+func (e *EventHandler) OnApply(ctx context.Context, resource framework.Resource, kind string, change interface{}, err error) {
+}
+
+func (e *EventHandler) OnResourceEnd(ctx context.Context, resource framework.Resource, err error) {
+}
+
+// OnReconcileEnd records a Warning event carrying err's message when
+// reconciliation failed, or a Normal "Reconciled" event on success. obj must
+// implement runtime.Object for the event to be attached to it; otherwise the
+// event is dropped and a warning is logged.
+func (e *EventHandler) OnReconcileEnd(ctx context.Context, obj interface{}, err error) {
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		e.logger.LogCtx(ctx, "warning", "cannot record event, object does not implement runtime.Object")
+		return
+	}
+
+	if err != nil {
+		e.recorder.Event(runtimeObj, corev1.EventTypeWarning, "ReconcileFailed", microerror.Mask(err).Error())
+		return
+	}
+
+	e.recorder.Event(runtimeObj, corev1.EventTypeNormal, "Reconciled", "reconciliation succeeded")
+}
@@ -0,0 +1,23 @@
+package leaderelection
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+// lostLeadershipError is used as the cancellation cause of the context passed
+// to Framework.Boot once this replica stops being the leader.
+var lostLeadershipError = &microerror.Error{
+	Kind: "lostLeadershipError",
+}
+
+// IsLostLeadership asserts lostLeadershipError.
+func IsLostLeadership(err error) bool {
+	return microerror.Cause(err) == lostLeadershipError
+}
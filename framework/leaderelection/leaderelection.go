@@ -0,0 +1,298 @@
+// Package leaderelection wraps Framework.Boot so that, of several operator
+// replicas watching the same custom resource, only the one holding a
+// Kubernetes lease ever reconciles. The informer watch must not start before
+// leadership is acquired, which is why Framework.Boot is only ever called
+// from inside OnStartedLeading below, never directly by a caller using this
+// package.
+package leaderelection
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/giantswarm/operatorkit/framework"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// Config represents the configuration used to create a new LeaderElector.
+type Config struct {
+	Logger micrologger.Logger
+	Client kubernetes.Interface
+
+	// Namespace and Name identify the Lease object used to coordinate
+	// leadership between replicas.
+	Namespace string
+	Name      string
+	// Identity identifies this replica in the Lease object. Defaults to the
+	// host name plus a random uuid, which is unique per process and therefore
+	// safe even when the host name is shared, e.g. by several containers in
+	// one Pod.
+	Identity string
+
+	// LeaseDuration, RenewDeadline and RetryPeriod configure the underlying
+	// client-go leader election client. They default to 15s, 10s and 2s,
+	// which are the defaults client-go itself recommends.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// OnLostLeadership, if set, is called after this replica stopped being the
+	// leader, e.g. to let callers emit their own metrics or alerts.
+	OnLostLeadership func()
+}
+
+// LeaderElector wraps Framework.Boot so it only ever runs while this replica
+// holds the configured Lease.
+type LeaderElector struct {
+	logger micrologger.Logger
+	client kubernetes.Interface
+
+	namespace string
+	name      string
+	identity  string
+
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	onLostLeadership func()
+
+	metrics *metrics
+}
+
+// New creates a new configured LeaderElector.
+func New(config Config) (*LeaderElector, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+	if config.Client == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Client must not be empty")
+	}
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Namespace must not be empty")
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+
+	if config.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		config.Identity = hostname + "_" + uuid.New().String()
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = defaultLeaseDuration
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = defaultRenewDeadline
+	}
+	if config.RetryPeriod == 0 {
+		config.RetryPeriod = defaultRetryPeriod
+	}
+
+	e := &LeaderElector{
+		logger: config.Logger,
+		client: config.Client,
+
+		namespace: config.Namespace,
+		name:      config.Name,
+		identity:  config.Identity,
+
+		leaseDuration: config.LeaseDuration,
+		renewDeadline: config.RenewDeadline,
+		retryPeriod:   config.RetryPeriod,
+
+		onLostLeadership: config.OnLostLeadership,
+
+		metrics: newMetrics(config.Namespace, config.Name),
+	}
+
+	return e, nil
+}
+
+// Run participates in leader election for e's configured Lease. It blocks
+// until ctx is cancelled or, once this replica has acquired leadership, until
+// it loses it again, and then returns the error f.Boot returned, if any. f.Boot is only ever invoked while this replica holds
+// the lease: client-go calls OnStartedLeading with a context that is
+// cancelled as soon as leadership is lost, which in turn makes f's informer
+// loop observe the cancellation and shut down, exactly like any other
+// Framework.Boot caller coordinating shutdown through a shared context.
+func (e *LeaderElector) Run(ctx context.Context, f *framework.Framework) error {
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		e.namespace,
+		e.name,
+		e.client.CoreV1(),
+		e.client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity: e.identity,
+		},
+	)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// client-go invokes OnStartedLeading via "go callbacks.OnStartedLeading(ctx)"
+	// rather than blocking on it, so elector.Run(ctx) can return before that
+	// goroutine has even begun running, e.g. when ctx is already cancelled and
+	// the subsequent renew loop returns almost immediately. boot tracks that
+	// handoff explicitly instead of taking an unsynchronized snapshot right
+	// after elector.Run(ctx) returns: OnStartedLeading reports its own start
+	// synchronously, so Run below only decides it never started once it has
+	// had every chance to observe that signal.
+	boot := newBootCoordinator()
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   e.leaseDuration,
+		RenewDeadline:   e.renewDeadline,
+		RetryPeriod:     e.retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				boot.starting()
+				defer boot.done()
+
+				e.logger.LogCtx(leaderCtx, "debug", "acquired leadership", "identity", e.identity)
+
+				e.metrics.elected.Set(1)
+				e.metrics.transitions.Inc()
+
+				bootCtx, cancel := context.WithCancelCause(leaderCtx)
+				boot.setCancel(cancel)
+
+				boot.setErr(f.Boot(bootCtx))
+			},
+			OnStoppedLeading: func() {
+				e.logger.LogCtx(ctx, "debug", "lost leadership", "identity", e.identity)
+
+				e.metrics.elected.Set(0)
+
+				boot.stop(microerror.Mask(lostLeadershipError))
+
+				if e.onLostLeadership != nil {
+					e.onLostLeadership()
+				}
+			},
+		},
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(ctx)
+	}()
+
+	return boot.wait(electorDone)
+}
+
+// bootCoordinator synchronizes OnStartedLeading's goroutine, which client-go
+// spawns without waiting for it to start, with Run's decision about whether
+// to wait for f.Boot and which error it returned. Reads and writes of cancel
+// and err go through mu since they happen from OnStartedLeading's goroutine
+// on one side and OnStoppedLeading/wait on the other; startedCh lets wait
+// learn, without taking a racy snapshot, whether OnStartedLeading ever ran at
+// all.
+type bootCoordinator struct {
+	startedCh chan struct{}
+	bootWg    sync.WaitGroup
+
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	err    error
+}
+
+func newBootCoordinator() *bootCoordinator {
+	return &bootCoordinator{
+		startedCh: make(chan struct{}),
+	}
+}
+
+// starting records that OnStartedLeading began running and unblocks wait,
+// which otherwise cannot tell this apart from OnStartedLeading never having
+// run at all. It must be called before anything else OnStartedLeading does,
+// and exactly once.
+func (c *bootCoordinator) starting() {
+	c.bootWg.Add(1)
+	close(c.startedCh)
+}
+
+// done marks f.Boot as finished, letting a blocked wait proceed. It must be
+// deferred by OnStartedLeading right after starting.
+func (c *bootCoordinator) done() {
+	c.bootWg.Done()
+}
+
+func (c *bootCoordinator) setCancel(cancel context.CancelCauseFunc) {
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+}
+
+func (c *bootCoordinator) setErr(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+// stop cancels f.Boot's context with cause, if OnStartedLeading has set one
+// up. It is a no-op when this replica never acquired leadership, which is
+// why OnStoppedLeading calling it unconditionally is safe.
+func (c *bootCoordinator) stop(cause error) {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel(cause)
+	}
+}
+
+// wait blocks until it can determine, definitively, whether OnStartedLeading
+// ever ran: either startedCh closes, or electorDone closes first because
+// elector.Run returned without this replica ever acquiring leadership. In the
+// latter case it re-checks startedCh once more non-blockingly, since
+// OnStartedLeading's goroutine could in principle still be racing to start
+// right as elector.Run returns. Once it knows boot started, it waits for
+// f.Boot to actually finish before returning its error.
+func (c *bootCoordinator) wait(electorDone <-chan struct{}) error {
+	started := false
+
+	select {
+	case <-c.startedCh:
+		started = true
+	case <-electorDone:
+		select {
+		case <-c.startedCh:
+			started = true
+		default:
+		}
+	}
+
+	if started {
+		c.bootWg.Wait()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
@@ -0,0 +1,91 @@
+package leaderelection
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBootCoordinatorConcurrentStart reproduces the scenario that used to
+// race in Run: OnStartedLeading runs on its own goroutine, which client-go
+// spawns without waiting for it, so wait (standing in for Run's post-
+// elector.Run bookkeeping) can be invoked before that goroutine has made any
+// progress. wait must still notice the concurrent start, whichever
+// goroutine happens to run first, and block until f.Boot is done instead of
+// concluding it never started. Run with -race to catch unsynchronized
+// access to cancel/err.
+func TestBootCoordinatorConcurrentStart(t *testing.T) {
+	boot := newBootCoordinator()
+	// electorDone is deliberately never closed: this replica holds leadership
+	// for the duration of the test, so wait must resolve via startedCh alone.
+	electorDone := make(chan struct{})
+
+	bootErr := errors.New("boom")
+	go func() {
+		boot.starting()
+		defer boot.done()
+
+		boot.setErr(bootErr)
+	}()
+
+	err := boot.wait(electorDone)
+	if err != bootErr {
+		t.Fatalf("expected wait to return %v once boot finished, got %v", bootErr, err)
+	}
+}
+
+// TestBootCoordinatorNeverStarted verifies wait returns immediately with a
+// nil error when OnStartedLeading never runs at all, e.g. because this
+// replica never acquired leadership.
+func TestBootCoordinatorNeverStarted(t *testing.T) {
+	boot := newBootCoordinator()
+	electorDone := make(chan struct{})
+	close(electorDone)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- boot.wait(electorDone)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected nil error when boot never started, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return; it should never block when boot never started")
+	}
+}
+
+// TestBootCoordinatorStopCancelsInFlightBoot verifies stop cancels the
+// context OnStartedLeading set up for f.Boot, which is how OnStoppedLeading
+// unblocks an in-flight Boot call when leadership is lost.
+func TestBootCoordinatorStopCancelsInFlightBoot(t *testing.T) {
+	boot := newBootCoordinator()
+
+	cancelled := make(chan error, 1)
+	boot.setCancel(func(cause error) {
+		cancelled <- cause
+	})
+
+	cause := errors.New("lost leadership")
+	boot.stop(cause)
+
+	select {
+	case got := <-cancelled:
+		if got != cause {
+			t.Fatalf("expected cancel to be called with %v, got %v", cause, got)
+		}
+	default:
+		t.Fatal("expected stop to call the registered cancel func")
+	}
+}
+
+// TestBootCoordinatorStopWithoutStartIsNoOp verifies stop is safe to call
+// even when OnStartedLeading never ran, which is what lets OnStoppedLeading
+// call it unconditionally.
+func TestBootCoordinatorStopWithoutStartIsNoOp(t *testing.T) {
+	boot := newBootCoordinator()
+
+	boot.stop(errors.New("lost leadership"))
+}
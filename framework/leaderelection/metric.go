@@ -0,0 +1,54 @@
+package leaderelection
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	prometheusNamespace = "operatorkit"
+	prometheusSubsystem = "leader"
+)
+
+// metrics holds the Prometheus collectors for a single LeaderElector. A
+// process may run more than one LeaderElector against different Framework
+// instances (one per watched CRD), so instead of sharing package-level
+// variables each LeaderElector owns its own set, labeled by the Lease it
+// coordinates.
+type metrics struct {
+	elected     prometheus.Gauge
+	transitions prometheus.Counter
+}
+
+// newMetrics creates the Prometheus collectors for a LeaderElector
+// coordinating the Lease identified by namespace/name. Unlike the framework
+// package's own metrics these are not registered automatically: callers are
+// expected to call Collectors and register them, e.g. via
+// prometheus.MustRegister(elector.Collectors()...), since a process may run
+// more than one LeaderElector and shouldn't be forced to register every
+// instance's metrics under the same names.
+func newMetrics(namespace, name string) *metrics {
+	return &metrics{
+		elected: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   prometheusNamespace,
+				Subsystem:   prometheusSubsystem,
+				Name:        "elected",
+				Help:        "Set to 1 while this replica holds the leader lease, 0 otherwise.",
+				ConstLabels: prometheus.Labels{"namespace": namespace, "name": name},
+			},
+		),
+		transitions: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   prometheusNamespace,
+				Subsystem:   prometheusSubsystem,
+				Name:        "transitions_total",
+				Help:        "Total number of times this replica started leading.",
+				ConstLabels: prometheus.Labels{"namespace": namespace, "name": name},
+			},
+		),
+	}
+}
+
+// Collectors returns e's Prometheus collectors, for callers that want to
+// register them, e.g. via prometheus.MustRegister(e.Collectors()...).
+func (e *LeaderElector) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{e.metrics.elected, e.metrics.transitions}
+}
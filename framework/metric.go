@@ -0,0 +1,54 @@
+package framework
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	prometheusNamespace = "operatorkit"
+	prometheusSubsystem = "framework"
+)
+
+var (
+	frameworkHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "reconcile_duration_seconds",
+			Help:      "Histogram for the time it takes to reconcile an event, keyed by event type.",
+		},
+		[]string{"event"},
+	)
+
+	queueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "queue_depth",
+			Help:      "Number of keys currently queued for reconciliation.",
+		},
+	)
+
+	queueAdds = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "queue_adds_total",
+			Help:      "Total number of keys added to the reconciliation queue.",
+		},
+	)
+
+	queueRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "queue_retries_total",
+			Help:      "Total number of keys requeued with backoff after a failed reconciliation.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(frameworkHistogram)
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(queueAdds)
+	prometheus.MustRegister(queueRetries)
+}
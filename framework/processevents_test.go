@@ -0,0 +1,82 @@
+package framework
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// newProcessEventsTestFramework builds a Framework that can run
+// ProcessEvents on its own, bypassing Boot/bootWithError so the test never
+// needs informer.Interface, *k8scrdclient.CRDClient or *ResourceRouter, none
+// of which are defined in this tree. It only exercises ProcessEvents' own
+// worker/backoff/shutdown bookkeeping, never a real reconciliation, so
+// leaving resourceRouter unset is safe as long as the test never pushes
+// anything onto deleteChan/updateChan.
+func newProcessEventsTestFramework(t *testing.T, backOffFactory func() backoff.BackOff) *Framework {
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("micrologger.New: %s", err)
+	}
+
+	return &Framework{
+		logger:         logger,
+		pending:        make(map[string]watch.Event),
+		backOffFactory: backOffFactory,
+		concurrency:    1,
+		eventHandler:   nopEventHandler{},
+	}
+}
+
+// oneShotBackOff never retries, so ProcessEvents returns to the caller after
+// the first failed attempt instead of retrying for the lifetime of the test.
+func oneShotBackOff() backoff.BackOff {
+	return backoff.WithMaxTries(backoff.NewConstantBackOff(time.Millisecond), 1)
+}
+
+// TestProcessEventsShutsDownOnSharedContextCancellation verifies the
+// multi-Framework coordination Boot's doc comment describes: when one
+// Framework's ProcessEvents fails and the caller cancels a context shared
+// with other Frameworks, those other Frameworks observe ctx.Done() and
+// return promptly instead of continuing to run.
+func TestProcessEventsShutsDownOnSharedContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	failing := newProcessEventsTestFramework(t, oneShotBackOff)
+	healthy := newProcessEventsTestFramework(t, oneShotBackOff)
+
+	failingErrChan := make(chan error, 1)
+	failingErrChan <- microerror.Maskf(executionFailedError, "simulated informer failure")
+
+	failingDone := make(chan error, 1)
+	go func() {
+		failingDone <- failing.ProcessEvents(ctx, make(chan watch.Event), make(chan watch.Event), failingErrChan)
+	}()
+
+	healthyDone := make(chan error, 1)
+	go func() {
+		healthyDone <- healthy.ProcessEvents(ctx, make(chan watch.Event), make(chan watch.Event), make(chan error))
+	}()
+
+	select {
+	case err := <-failingDone:
+		if err == nil {
+			t.Fatal("expected failing Framework's ProcessEvents to return an error")
+		}
+		cancel(err)
+	case <-time.After(time.Second):
+		t.Fatal("failing Framework's ProcessEvents did not return in time")
+	}
+
+	select {
+	case <-healthyDone:
+	case <-time.After(time.Second):
+		t.Fatal("healthy Framework's ProcessEvents did not shut down within the deadline after the shared context was cancelled")
+	}
+}
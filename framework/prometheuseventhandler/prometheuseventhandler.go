@@ -0,0 +1,106 @@
+// Package prometheuseventhandler implements framework.EventHandler with
+// per-resource, per-phase Prometheus metrics, replacing the need for a single
+// framework-wide reconciliation histogram.
+package prometheuseventhandler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/giantswarm/operatorkit/framework"
+)
+
+const (
+	prometheusNamespace = "operatorkit"
+	prometheusSubsystem = "resource"
+)
+
+// EventHandler implements framework.EventHandler by recording how often and
+// how long each resource's reconciliation phase takes, and how often it
+// fails.
+type EventHandler struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+
+	starts sync.Map
+}
+
+// New creates a new configured EventHandler. Its metrics are registered with
+// the default Prometheus registry.
+func New() *EventHandler {
+	e := &EventHandler{
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prometheusNamespace,
+				Subsystem: prometheusSubsystem,
+				Name:      "duration_seconds",
+				Help:      "Histogram for the time it takes a resource to go through a reconciliation phase.",
+			},
+			[]string{"resource", "phase"},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prometheusNamespace,
+				Subsystem: prometheusSubsystem,
+				Name:      "errors_total",
+				Help:      "Total number of errors returned by a resource during a reconciliation phase.",
+			},
+			[]string{"resource", "phase"},
+		),
+	}
+
+	prometheus.MustRegister(e.duration)
+	prometheus.MustRegister(e.errors)
+
+	return e
+}
+
+func (e *EventHandler) OnResourceStart(ctx context.Context, resource framework.Resource, phase string) {
+	e.starts.Store(e.key(ctx, resource, phase), time.Now())
+}
+
+func (e *EventHandler) OnGetCurrentState(ctx context.Context, resource framework.Resource, currentState interface{}, err error) {
+}
+
+func (e *EventHandler) OnPatchComputed(ctx context.Context, resource framework.Resource, patch *framework.Patch) {
+}
+
+func (e *EventHandler) OnApply(ctx context.Context, resource framework.Resource, kind string, change interface{}, err error) {
+}
+
+// OnResourceEnd observes the duration since the matching OnResourceStart and
+// counts err, if any, against resource/phase.
+func (e *EventHandler) OnResourceEnd(ctx context.Context, resource framework.Resource, err error) {
+	for _, phase := range []string{"delete", "update"} {
+		key := e.key(ctx, resource, phase)
+
+		v, ok := e.starts.Load(key)
+		if !ok {
+			continue
+		}
+		e.starts.Delete(key)
+
+		start := v.(time.Time)
+		e.duration.WithLabelValues(resource.Name(), phase).Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			e.errors.WithLabelValues(resource.Name(), phase).Inc()
+		}
+
+		return
+	}
+}
+
+func (e *EventHandler) OnReconcileEnd(ctx context.Context, obj interface{}, err error) {}
+
+// key identifies a single resource's in-flight reconciliation phase. ctx is
+// part of the key because the same Resource instance is shared across every
+// object the framework reconciles, so the resource name alone is not unique
+// across concurrently running reconciliations.
+func (e *EventHandler) key(ctx context.Context, resource framework.Resource, phase string) string {
+	return fmt.Sprintf("%p:%s:%s", ctx, resource.Name(), phase)
+}